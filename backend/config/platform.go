@@ -0,0 +1,138 @@
+// Package config resolves the set of RPOrigins WebAuthn should accept,
+// merging plain web origins with the native-app origins used by the
+// Android and iOS passkey flows.
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// IOSApp is an iOS app allowed to use this RP via an associated domain
+// (apple-app-site-association webcredentials entry).
+type IOSApp struct {
+	TeamID   string `json:"team_id"`
+	BundleID string `json:"bundle_id"`
+}
+
+// AppID is the "<TeamID>.<BundleID>" identifier Apple expects in
+// apple-app-site-association.
+func (a IOSApp) AppID() string {
+	return a.TeamID + "." + a.BundleID
+}
+
+// AndroidApp is an Android app allowed to use this RP, identified by its
+// package name and APK signing certificate fingerprint.
+type AndroidApp struct {
+	Package             string `json:"package"`
+	SHA256CertFingerprint string `json:"sha256_cert_fingerprint"`
+}
+
+// Origin returns the "android:apk-key-hash:<base64url>" origin WebAuthn
+// expects for this app, per Google's Digital Asset Links spec.
+func (a AndroidApp) Origin() (string, error) {
+	hexHash := strings.ReplaceAll(a.SHA256CertFingerprint, ":", "")
+	raw, err := hex.DecodeString(hexHash)
+	if err != nil {
+		return "", err
+	}
+	return "android:apk-key-hash:" + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Platform describes every origin - web and native - allowed to talk to
+// this RP, plus the native apps the server needs to vouch for via the
+// /.well-known endpoints.
+type Platform struct {
+	RPID        string       `json:"rp_id"`
+	WebOrigins  []string     `json:"web_origins"`
+	IOSApps     []IOSApp     `json:"ios_apps"`
+	AndroidApps []AndroidApp `json:"android_apps"`
+}
+
+// Load reads a platform config file. A missing or unreadable file, or one
+// that is not valid JSON, returns the error; main falls back to the plain
+// RP_ORIGINS env var on any error.
+func Load(path string) (*Platform, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Platform
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RPOrigins merges web, iOS, and Android origins into the single list
+// webauthn.Config.RPOrigins expects.
+func (p *Platform) RPOrigins() ([]string, error) {
+	origins := append([]string{}, p.WebOrigins...)
+
+	// iOS apps authenticate via the RP's own https origin, so one iOS app
+	// configured is enough to add it.
+	if len(p.IOSApps) > 0 {
+		origins = append(origins, "https://"+p.RPID)
+	}
+
+	for _, app := range p.AndroidApps {
+		origin, err := app.Origin()
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, origin)
+	}
+
+	return dedupe(origins), nil
+}
+
+func dedupe(origins []string) []string {
+	seen := make(map[string]bool, len(origins))
+	out := make([]string, 0, len(origins))
+	for _, o := range origins {
+		if seen[o] {
+			continue
+		}
+		seen[o] = true
+		out = append(out, o)
+	}
+	return out
+}
+
+// AssetLinks renders the assetlinks.json body Android Digital Asset Links
+// verification expects at /.well-known/assetlinks.json.
+func (p *Platform) AssetLinks() []map[string]any {
+	statements := make([]map[string]any, 0, len(p.AndroidApps))
+	for _, app := range p.AndroidApps {
+		statements = append(statements, map[string]any{
+			"relation": []string{
+				"delegate_permission/common.handle_all_urls",
+				"delegate_permission/common.get_login_creds",
+			},
+			"target": map[string]any{
+				"namespace":              "android_app",
+				"package_name":           app.Package,
+				"sha256_cert_fingerprints": []string{app.SHA256CertFingerprint},
+			},
+		})
+	}
+	return statements
+}
+
+// AppleAppSiteAssociation renders the body expected at
+// /.well-known/apple-app-site-association.
+func (p *Platform) AppleAppSiteAssociation() map[string]any {
+	appIDs := make([]string, 0, len(p.IOSApps))
+	for _, app := range p.IOSApps {
+		appIDs = append(appIDs, app.AppID())
+	}
+	return map[string]any{
+		"webcredentials": map[string]any{
+			"apps": appIDs,
+		},
+	}
+}
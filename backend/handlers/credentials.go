@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/lib/pq"
+	"learn-passkeys.com/m/models"
+)
+
+// ListCredentials returns the authenticated user's enrolled passkeys.
+func (h *Handler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUserID(r.Context())
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := h.DB.Query(
+		"SELECT id, transports, aaguid, name, created_at, last_used_at FROM credentials WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		http.Error(w, "Failed to load credentials", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	credentials := []models.Credential{}
+	for rows.Next() {
+		var c models.Credential
+		if err := rows.Scan(&c.ID, pq.Array(&c.Transports), &c.AAGUID, &c.Name, &c.CreatedAt, &c.LastUsedAt); err != nil {
+			http.Error(w, "Failed to scan credential", http.StatusInternalServerError)
+			return
+		}
+		credentials = append(credentials, c)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Error iterating credentials", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credentials)
+}
+
+// RenameCredential sets a friendly label on one of the authenticated user's
+// passkeys.
+func (h *Handler) RenameCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUserID(r.Context())
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, err := base64.RawURLEncoding.DecodeString(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid credential id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.DB.Exec(
+		"UPDATE credentials SET name = $1 WHERE id = $2 AND user_id = $3",
+		req.Name, id, userID,
+	)
+	if err != nil {
+		http.Error(w, "Failed to rename credential", http.StatusInternalServerError)
+		return
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// RevokeCredential deletes one of the authenticated user's passkeys.
+func (h *Handler) RevokeCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUserID(r.Context())
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := base64.RawURLEncoding.DecodeString(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid credential id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		http.Error(w, "Failed to revoke credential", http.StatusInternalServerError)
+		return
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"learn-passkeys.com/m/models"
+)
+
+// BeginDiscoverableLogin starts a usernameless login: the browser/authenticator
+// picks which resident credential to present, so we don't need a username up
+// front and don't send an allowCredentials list.
+func (h *Handler) BeginDiscoverableLogin(w http.ResponseWriter, r *http.Request) {
+	options, sessionData, err := h.WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		http.Error(w, "Failed to begin discoverable login", http.StatusInternalServerError)
+		return
+	}
+
+	// store session data with no associated user yet - it's resolved from the
+	// credential ID and userHandle the authenticator returns on finish
+	_, err = h.DB.Exec("INSERT INTO challenges (user_id, challenge, type, expires_at) VALUES ($1, $2, $3, $4)",
+		nil, sessionData.Challenge, "discoverable_login", sessionData.Expires)
+	if err != nil {
+		http.Error(w, "Failed to store session data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(options); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// userByCredentialHandle looks up the user a resident credential belongs to,
+// using the rawID and userHandle the authenticator hands back.
+// FinishDiscoverableLogin passes it to ValidateDiscoverableLogin, which calls
+// it as the DiscoverableUserHandler.
+func (h *Handler) userByCredentialHandle(rawID, userHandle []byte) (webauthn.User, error) {
+	userID, err := uuid.FromBytes(userHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = h.DB.QueryRow("SELECT id, username, created_at FROM users WHERE id = $1", userID).
+		Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKey []byte
+	var signCount uint32
+	err = h.DB.QueryRow("SELECT public_key, sign_count FROM credentials WHERE id = $1 AND user_id = $2", rawID, user.ID).
+		Scan(&publicKey, &signCount)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Credentials = []webauthn.Credential{{
+		ID:        rawID,
+		PublicKey: publicKey,
+		Authenticator: webauthn.Authenticator{
+			SignCount: signCount,
+		},
+	}}
+
+	return &user, nil
+}
+
+func (h *Handler) FinishDiscoverableLogin(w http.ResponseWriter, r *http.Request) {
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid credential response", http.StatusBadRequest)
+		return
+	}
+
+	challenge := parsedResponse.Response.CollectedClientData.Challenge
+
+	var sessionDataJSON []byte
+	err = h.DB.QueryRow(
+		"SELECT challenge FROM challenges WHERE challenge = $1 AND type = $2 AND user_id IS NULL AND expires_at > $3",
+		challenge, "discoverable_login", time.Now(),
+	).Scan(&sessionDataJSON)
+	if err != nil {
+		http.Error(w, "Challenge not found", http.StatusBadRequest)
+		return
+	}
+
+	sessionData := webauthn.SessionData{
+		Challenge:        challenge,
+		UserVerification: protocol.VerificationRequired,
+	}
+
+	credential, err := h.WebAuthn.ValidateDiscoverableLogin(h.userByCredentialHandle, sessionData, parsedResponse)
+	if err != nil {
+		http.Error(w, "Failed to validate login: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var userID uuid.UUID
+	err = h.DB.QueryRow("SELECT user_id FROM credentials WHERE id = $1", credential.ID).Scan(&userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.DB.Exec("UPDATE credentials SET sign_count = $1, last_used_at = $2 WHERE id = $3",
+		credential.Authenticator.SignCount, time.Now(), credential.ID)
+	if err != nil {
+		http.Error(w, "Failed to update sign count", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.DB.Exec("DELETE FROM challenges WHERE challenge = $1 AND type = $2", challenge, "discoverable_login")
+	if err != nil {
+		http.Error(w, "Failed to delete challenge", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.SessionManager.RenewToken(r.Context()); err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+	h.SessionManager.Put(r.Context(), sessionUserIDKey, userID.String())
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Login successful"})
+}
@@ -3,10 +3,21 @@ package handlers
 import (
 	"database/sql"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/go-webauthn/webauthn/webauthn"
+	"learn-passkeys.com/m/config"
+	"learn-passkeys.com/m/metadata"
 )
 
 type Handler struct {
-	DB       *sql.DB
-	WebAuthn *webauthn.WebAuthn
+	DB             *sql.DB
+	WebAuthn       *webauthn.WebAuthn
+	SessionManager *scs.SessionManager
+	Platform       *config.Platform
+
+	// Metadata is the FIDO MDS3 cache used to identify and, if
+	// RequireAttestation is set, gate authenticators by AAGUID at
+	// registration. Nil when attestation enforcement is disabled.
+	Metadata           *metadata.Cache
+	RequireAttestation bool
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
@@ -166,9 +167,10 @@ func (h *Handler) FinishLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update the credential's sign_count in the database (detect cloned authenticators)
-	_, err = h.DB.Exec("UPDATE credentials SET sign_count = $1 WHERE id = $2",
-		credential.Authenticator.SignCount, credential.ID)
+	// Update the credential's sign_count (detect cloned authenticators) and
+	// last_used_at in the database
+	_, err = h.DB.Exec("UPDATE credentials SET sign_count = $1, last_used_at = $2 WHERE id = $3",
+		credential.Authenticator.SignCount, time.Now(), credential.ID)
 	if err != nil {
 		http.Error(w, "Failed to update sign count", http.StatusInternalServerError)
 		return
@@ -181,6 +183,15 @@ func (h *Handler) FinishLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Mint an authenticated session for the user so downstream requests can
+	// tell who's logged in. Renew the token first to guard against session
+	// fixation across the login boundary.
+	if err := h.SessionManager.RenewToken(r.Context()); err != nil {
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+	h.SessionManager.Put(r.Context(), sessionUserIDKey, user.ID.String())
+
 	// Return success
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Login successful", "user": user.Username})
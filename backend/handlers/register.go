@@ -10,6 +10,7 @@ import (
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"learn-passkeys.com/m/models"
 )
 
@@ -51,12 +52,26 @@ func (h *Handler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// generate registration options
+	// generate registration options. Require a resident (discoverable) key so
+	// the credential can later be used for usernameless login. When
+	// RequireAttestation is on, ask for direct attestation instead of none so
+	// CreateCredential has a statement it can check against MDS3.
+	conveyance := protocol.PreferNoAttestation
+	attestationFormats := []protocol.AttestationFormat{protocol.AttestationFormatNone}
+	if h.RequireAttestation {
+		conveyance = protocol.PreferDirectAttestation
+		attestationFormats = nil
+	}
+
+	requireResidentKey := true
 	options, sessionData, err := h.WebAuthn.BeginRegistration(
 		&user,
-		webauthn.WithConveyancePreference(protocol.PreferNoAttestation),
-		webauthn.WithAttestationFormats([]protocol.AttestationFormat{
-			protocol.AttestationFormatNone,
+		webauthn.WithConveyancePreference(conveyance),
+		webauthn.WithAttestationFormats(attestationFormats),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: &requireResidentKey,
+			UserVerification:   protocol.VerificationRequired,
 		}),
 	)
 	if err != nil {
@@ -64,18 +79,20 @@ func (h *Handler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// DEBUG: Print full SessionData
-	fmt.Printf("DEBUG BeginRegistration SessionData: %+v\n", sessionData)
-
-	// Serialize entire SessionData to JSON
+	// Serialize the full SessionData so FinishRegistration can rebuild it
+	// (it needs more than the challenge - the exclusion list, user
+	// verification requirement, etc.) The challenge itself is stored in its
+	// own indexed column and is what FinishRegistration looks the row up by.
 	sessionDataJSON, err := json.Marshal(sessionData)
 	if err != nil {
 		http.Error(w, "Failed to marshal session data", http.StatusInternalServerError)
 		return
 	}
 
-	// store session data in database
-	_, err = db.Exec("INSERT INTO challenges (user_id, challenge, type, expires_at) VALUES ($1, $2, $3, $4)", user.ID, sessionDataJSON, "registration", sessionData.Expires)
+	_, err = db.Exec(
+		"INSERT INTO challenges (user_id, challenge, session_data, type, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		user.ID, sessionData.Challenge, sessionDataJSON, "registration", sessionData.Expires,
+	)
 	if err != nil {
 		http.Error(w, "Failed to store session data", http.StatusInternalServerError)
 		return
@@ -103,10 +120,15 @@ func (h *Handler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
 
 	challenge := parsedResponse.Response.CollectedClientData.Challenge
 
-	// Get the most recent registration challenge (for development simplicity)
+	// Look the challenge row up by the challenge value itself, not "most
+	// recent registration row" - that crossed wires between concurrent
+	// registrations under any real load.
 	var userID uuid.UUID
 	var sessionDataJSON []byte
-	err = h.DB.QueryRow("SELECT user_id, challenge FROM challenges WHERE type = $1 ORDER BY created_at DESC LIMIT 1", "registration").Scan(&userID, &sessionDataJSON)
+	err = h.DB.QueryRow(
+		"SELECT user_id, session_data FROM challenges WHERE challenge = $1 AND type = $2 AND expires_at > $3",
+		challenge, "registration", time.Now(),
+	).Scan(&userID, &sessionDataJSON)
 	if err != nil {
 		fmt.Printf("Challenge lookup error: %v\n", err)
 		http.Error(w, "Challenge not found", http.StatusBadRequest)
@@ -157,10 +179,33 @@ func (h *Handler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("DEBUG: Credential details: %+v\n", credential)
 	fmt.Printf("DEBUG: Authenticator: %+v\n", credential.Authenticator)
 
-	// 5. Save credential to database with backup flags
+	// When attestation enforcement is on, reject authenticators that aren't
+	// on the allow list or fall below the configured certification level.
+	var authenticatorName, certificationLevel string
+	aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID)
+	if err == nil && h.Metadata != nil {
+		if h.RequireAttestation && !h.Metadata.Allowed(aaguid.String()) {
+			http.Error(w, "Authenticator is not permitted by this deployment's policy", http.StatusForbidden)
+			return
+		}
+		if entry, ok := h.Metadata.Entry(aaguid.String()); ok {
+			authenticatorName = entry.Name
+			certificationLevel = entry.CertificationLevel
+		}
+	}
+
+	// 5. Save credential to database with backup flags. name and
+	// last_used_at always get an explicit value so later SELECTs (e.g.
+	// ListCredentials) can scan them into non-nullable fields.
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+
+	now := time.Now()
 	_, err = h.DB.Exec(`INSERT INTO credentials
-		(id, user_id, public_key, sign_count, backup_eligible, backup_state, attestation_type, aaguid, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		(id, user_id, public_key, sign_count, backup_eligible, backup_state, attestation_type, aaguid, authenticator_name, certification_level, transports, name, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)`,
 		credential.ID,
 		user.ID,
 		credential.PublicKey,
@@ -169,7 +214,11 @@ func (h *Handler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
 		credential.Flags.BackupState,
 		credential.AttestationType,
 		credential.Authenticator.AAGUID,
-		time.Now(),
+		authenticatorName,
+		certificationLevel,
+		pq.Array(transports),
+		"",
+		now,
 	)
 	if err != nil {
 		http.Error(w, "Failed to save credential", http.StatusInternalServerError)
@@ -177,7 +226,7 @@ func (h *Handler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 6. Delete used challenge
-	_, err = h.DB.Exec("DELETE FROM challenges WHERE user_id = $1 AND type = $2", user.ID, "registration")
+	_, err = h.DB.Exec("DELETE FROM challenges WHERE challenge = $1 AND type = $2", challenge, "registration")
 	if err != nil {
 		http.Error(w, "Failed to delete challenge", http.StatusInternalServerError)
 		return
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"learn-passkeys.com/m/models"
+)
+
+// BeginAddCredential lets an already-logged-in user enroll an additional
+// passkey. It's the same dance as BeginRegistration, except the user comes
+// from the session instead of a freshly-created account, and their existing
+// credential IDs are excluded so the authenticator can't register a
+// duplicate.
+func (h *Handler) BeginAddCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUserID(r.Context())
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	err := h.DB.QueryRow("SELECT id, username, created_at FROM users WHERE id = $1", userID).
+		Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.DB.Query("SELECT id FROM credentials WHERE user_id = $1", user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load user credentials", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var exclusions []protocol.CredentialDescriptor
+	for rows.Next() {
+		var credID []byte
+		if err := rows.Scan(&credID); err != nil {
+			http.Error(w, "Failed to scan credential", http.StatusInternalServerError)
+			return
+		}
+		exclusions = append(exclusions, protocol.CredentialDescriptor{
+			Type:         protocol.PublicKeyCredentialType,
+			CredentialID: credID,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Error iterating credentials", http.StatusInternalServerError)
+		return
+	}
+
+	// Mirror BeginRegistration's attestation policy: when RequireAttestation
+	// is on, ask for direct attestation so adding a credential is gated by
+	// the same MDS3 allow/deny policy as a brand new account.
+	conveyance := protocol.PreferNoAttestation
+	attestationFormats := []protocol.AttestationFormat{protocol.AttestationFormatNone}
+	if h.RequireAttestation {
+		conveyance = protocol.PreferDirectAttestation
+		attestationFormats = nil
+	}
+
+	requireResidentKey := true
+	options, sessionData, err := h.WebAuthn.BeginRegistration(
+		&user,
+		webauthn.WithConveyancePreference(conveyance),
+		webauthn.WithAttestationFormats(attestationFormats),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: &requireResidentKey,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		http.Error(w, "Failed to begin registration", http.StatusInternalServerError)
+		return
+	}
+
+	sessionDataJSON, err := json.Marshal(sessionData)
+	if err != nil {
+		http.Error(w, "Failed to marshal session data", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.DB.Exec(
+		"INSERT INTO challenges (user_id, challenge, session_data, type, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		user.ID, sessionData.Challenge, sessionDataJSON, "registration", sessionData.Expires,
+	)
+	if err != nil {
+		http.Error(w, "Failed to store session data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(options); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// FinishAddCredential verifies the new authenticator and attaches it to the
+// already-logged-in user, the same way FinishRegistration does for a brand
+// new account.
+func (h *Handler) FinishAddCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUserID(r.Context())
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBody(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid credential response", http.StatusBadRequest)
+		return
+	}
+
+	challenge := parsedResponse.Response.CollectedClientData.Challenge
+
+	var sessionDataJSON []byte
+	err = h.DB.QueryRow(
+		"SELECT session_data FROM challenges WHERE challenge = $1 AND user_id = $2 AND type = $3 AND expires_at > $4",
+		challenge, userID, "registration", time.Now(),
+	).Scan(&sessionDataJSON)
+	if err != nil {
+		http.Error(w, "Challenge not found", http.StatusBadRequest)
+		return
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(sessionDataJSON, &sessionData); err != nil {
+		http.Error(w, "Failed to unmarshal session data", http.StatusInternalServerError)
+		return
+	}
+
+	if string(sessionData.Challenge) != string(challenge) {
+		http.Error(w, "Challenge mismatch", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	err = h.DB.QueryRow("SELECT id, username, created_at FROM users WHERE id = $1", userID).
+		Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := h.WebAuthn.CreateCredential(&user, sessionData, parsedResponse)
+	if err != nil {
+		http.Error(w, "Failed to finish registration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Same allow/deny policy as FinishRegistration: don't let attestation
+	// enforcement be bypassed just because the user is already logged in.
+	var authenticatorName, certificationLevel string
+	aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID)
+	if err == nil && h.Metadata != nil {
+		if h.RequireAttestation && !h.Metadata.Allowed(aaguid.String()) {
+			http.Error(w, "Authenticator is not permitted by this deployment's policy", http.StatusForbidden)
+			return
+		}
+		if entry, ok := h.Metadata.Entry(aaguid.String()); ok {
+			authenticatorName = entry.Name
+			certificationLevel = entry.CertificationLevel
+		}
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+
+	now := time.Now()
+	_, err = h.DB.Exec(`INSERT INTO credentials
+		(id, user_id, public_key, sign_count, backup_eligible, backup_state, attestation_type, aaguid, authenticator_name, certification_level, transports, name, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)`,
+		credential.ID,
+		user.ID,
+		credential.PublicKey,
+		credential.Authenticator.SignCount,
+		credential.Flags.BackupEligible,
+		credential.Flags.BackupState,
+		credential.AttestationType,
+		credential.Authenticator.AAGUID,
+		authenticatorName,
+		certificationLevel,
+		pq.Array(transports),
+		"",
+		now,
+	)
+	if err != nil {
+		http.Error(w, "Failed to save credential", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.DB.Exec("DELETE FROM challenges WHERE challenge = $1 AND type = $2", challenge, "registration")
+	if err != nil {
+		http.Error(w, "Failed to delete challenge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Credential added",
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// sessionUserIDKey is the SCS session key the authenticated user's UUID is stored under.
+const sessionUserIDKey = "user_id"
+
+// RequireAuth wraps a handler so it only runs when the request carries a valid
+// session. It responds with 401 if no user is logged in.
+func (h *Handler) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.SessionManager.Exists(r.Context(), sessionUserIDKey) {
+			http.Error(w, "Not authenticated", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// currentUserID returns the UUID stored in the session, if any.
+func (h *Handler) currentUserID(ctx context.Context) (uuid.UUID, bool) {
+	raw := h.SessionManager.GetString(ctx, sessionUserIDKey)
+	if raw == "" {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.currentUserID(r.Context())
+	if !ok {
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var username string
+	var createdAt string
+	err := h.DB.QueryRow("SELECT username, created_at FROM users WHERE id = $1", userID).Scan(&username, &createdAt)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":         userID.String(),
+		"username":   username,
+		"created_at": createdAt,
+	})
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.SessionManager.Destroy(r.Context()); err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AssetLinks serves /.well-known/assetlinks.json so Android can verify this
+// server controls the apps listed in the platform config.
+func (h *Handler) AssetLinks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Platform.AssetLinks())
+}
+
+// AppleAppSiteAssociation serves /.well-known/apple-app-site-association so
+// iOS can verify the associated-domain apps listed in the platform config.
+func (h *Handler) AppleAppSiteAssociation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Platform.AppleAppSiteAssociation())
+}
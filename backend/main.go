@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/v2"
 	"github.com/go-webauthn/webauthn/webauthn"
+	"learn-passkeys.com/m/config"
 	"learn-passkeys.com/m/db"
 	"learn-passkeys.com/m/handlers"
+	"learn-passkeys.com/m/metadata"
 )
 
 // CORS middleware
@@ -29,7 +38,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
@@ -43,6 +52,59 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// loadMetadataCache builds the FIDO MDS3 cache from env configuration:
+// FIDO_MDS_ROOT_CA_PATH (required), FIDO_MDS_BLOB_URL (optional override),
+// and FIDO_MDS_ALLOWED_AAGUIDS / FIDO_MDS_DENIED_AAGUIDS / FIDO_MDS_MIN_CERTIFICATION_LEVEL
+// for the enterprise allow/deny policy.
+func loadMetadataCache() (*metadata.Cache, error) {
+	rootCAPath := os.Getenv("FIDO_MDS_ROOT_CA_PATH")
+	rootCAPEM, err := os.ReadFile(rootCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("read FIDO MDS root CA: %w", err)
+	}
+
+	block, _ := pem.Decode(rootCAPEM)
+	if block == nil {
+		return nil, fmt.Errorf("FIDO MDS root CA is not valid PEM")
+	}
+	rootCA, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse FIDO MDS root CA: %w", err)
+	}
+
+	return metadata.NewCache(metadata.Config{
+		BlobURL:               os.Getenv("FIDO_MDS_BLOB_URL"),
+		RootCA:                rootCA,
+		AllowedAAGUIDs:        parseAAGUIDSet(os.Getenv("FIDO_MDS_ALLOWED_AAGUIDS")),
+		DeniedAAGUIDs:         parseAAGUIDSet(os.Getenv("FIDO_MDS_DENIED_AAGUIDS")),
+		MinCertificationLevel: os.Getenv("FIDO_MDS_MIN_CERTIFICATION_LEVEL"),
+	})
+}
+
+// sweepExpiredChallenges deletes expired challenge rows every minute so the
+// table doesn't grow unbounded with abandoned registration/login attempts.
+func sweepExpiredChallenges(db *sql.DB) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := db.Exec("DELETE FROM challenges WHERE expires_at < $1", time.Now()); err != nil {
+			fmt.Printf("Challenge sweep error: %v\n", err)
+		}
+	}
+}
+
+func parseAAGUIDSet(csv string) map[string]bool {
+	if csv == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, aaguid := range strings.Split(csv, ",") {
+		set[strings.TrimSpace(aaguid)] = true
+	}
+	return set
+}
+
 func main() {
 	db, err := db.Connect()
 	if err != nil {
@@ -50,20 +112,36 @@ func main() {
 	}
 	defer db.Close()
 
+	go sweepExpiredChallenges(db)
+
 	// Get RP ID and origins from environment or use defaults
 	rpID := os.Getenv("RP_ID")
 	if rpID == "" {
 		rpID = "localhost"
 	}
 
-	rpOrigins := os.Getenv("RP_ORIGINS")
-	var origins []string
-	if rpOrigins == "" {
-		origins = []string{"http://localhost:5173"}
-	} else {
-		for _, origin := range strings.Split(rpOrigins, ",") {
-			origins = append(origins, strings.TrimSpace(origin))
+	// The platform config file (RP_PLATFORM_CONFIG) lists iOS and Android
+	// apps alongside plain web origins, so native apps can pass WebAuthn's
+	// origin check too. Fall back to the plain comma-separated RP_ORIGINS
+	// env var when there's no platform config.
+	platform, err := config.Load(os.Getenv("RP_PLATFORM_CONFIG"))
+	if err != nil {
+		platform = &config.Platform{RPID: rpID}
+		rpOrigins := os.Getenv("RP_ORIGINS")
+		if rpOrigins == "" {
+			platform.WebOrigins = []string{"http://localhost:5173"}
+		} else {
+			for _, origin := range strings.Split(rpOrigins, ",") {
+				platform.WebOrigins = append(platform.WebOrigins, strings.TrimSpace(origin))
+			}
 		}
+	} else if platform.RPID != "" {
+		rpID = platform.RPID
+	}
+
+	origins, err := platform.RPOrigins()
+	if err != nil {
+		panic(err)
 	}
 
 	wconfig := &webauthn.Config{
@@ -72,14 +150,41 @@ func main() {
 		RPOrigins:     origins,
 	}
 
+	// Attestation enforcement is opt-in: with FIDO_MDS_ENABLED=true, the
+	// server downloads the MDS3 BLOB, keeps it refreshed, and can reject
+	// enrollments from authenticators outside an enterprise allow list.
+	requireAttestation := os.Getenv("FIDO_MDS_ENABLED") == "true"
+	var mdsCache *metadata.Cache
+	if requireAttestation {
+		mdsCache, err = loadMetadataCache()
+		if err != nil {
+			panic(err)
+		}
+		mdsCache.Start(context.Background())
+		wconfig.MDS = mdsCache
+	}
+
 	webAuthn, err := webauthn.New(wconfig)
 	if err != nil {
 		panic(err)
 	}
 
+	// Sessions are stored in Postgres and handed to the client as an
+	// HttpOnly, SameSite cookie minted on successful login.
+	sessionManager := scs.New()
+	sessionManager.Store = postgresstore.New(db)
+	sessionManager.Lifetime = 24 * time.Hour
+	sessionManager.Cookie.HttpOnly = true
+	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	sessionManager.Cookie.Secure = os.Getenv("ENV") == "production"
+
 	handler := &handlers.Handler{
-		DB:       db,
-		WebAuthn: webAuthn,
+		DB:                 db,
+		WebAuthn:           webAuthn,
+		SessionManager:     sessionManager,
+		Platform:           platform,
+		Metadata:           mdsCache,
+		RequireAttestation: requireAttestation,
 	}
 
 	// Create a new ServeMux
@@ -88,9 +193,20 @@ func main() {
 	mux.HandleFunc("/register/finish", handler.FinishRegistration)
 	mux.HandleFunc("/login/begin", handler.BeginLogin)
 	mux.HandleFunc("/login/finish", handler.FinishLogin)
-
-	// Wrap the mux with CORS middleware
-	corsHandler := corsMiddleware(mux)
+	mux.HandleFunc("/login/discoverable/begin", handler.BeginDiscoverableLogin)
+	mux.HandleFunc("/login/discoverable/finish", handler.FinishDiscoverableLogin)
+	mux.HandleFunc("GET /me", handler.RequireAuth(handler.Me))
+	mux.HandleFunc("POST /logout", handler.Logout)
+	mux.HandleFunc("/.well-known/assetlinks.json", handler.AssetLinks)
+	mux.HandleFunc("/.well-known/apple-app-site-association", handler.AppleAppSiteAssociation)
+	mux.HandleFunc("GET /credentials", handler.RequireAuth(handler.ListCredentials))
+	mux.HandleFunc("PATCH /credentials/{id}", handler.RequireAuth(handler.RenameCredential))
+	mux.HandleFunc("DELETE /credentials/{id}", handler.RequireAuth(handler.RevokeCredential))
+	mux.HandleFunc("POST /register/add/begin", handler.RequireAuth(handler.BeginAddCredential))
+	mux.HandleFunc("POST /register/add/finish", handler.RequireAuth(handler.FinishAddCredential))
+
+	// Wrap the mux with the session manager, then CORS
+	corsHandler := corsMiddleware(sessionManager.LoadAndSave(mux))
 
 	fmt.Println("Server is running on http://localhost:8080")
 	http.ListenAndServe(":8080", corsHandler)
@@ -0,0 +1,159 @@
+package metadata
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// statement is the subset of a FIDO MDS3 metadataStatement entry we care
+// about. The real BLOB carries far more (upv, authenticatorGetInfo, icons,
+// ...) but the server only needs enough to label and gate a credential.
+type statement struct {
+	AAGUID                          string `json:"aaguid"`
+	Description                     string `json:"description"`
+	AuthenticatorCertificationLevel string `json:"authenticatorCertificationLevel"`
+}
+
+// tocEntry is one element of the BLOB payload's "entries" array.
+type tocEntry struct {
+	AAGUID            string    `json:"aaguid"`
+	StatusReports     []report  `json:"statusReports"`
+	MetadataStatement statement `json:"metadataStatement"`
+}
+
+type report struct {
+	Status                  string `json:"status"`
+	CertificationDescriptor string `json:"certificationDescriptor"`
+}
+
+// payload is the decoded JWT claims of the MDS3 BLOB.
+type payload struct {
+	NextUpdate string     `json:"nextUpdate"`
+	Entries    []tocEntry `json:"entries"`
+}
+
+// fetchBlob downloads and verifies the MDS3 BLOB, returning the authenticator
+// entries keyed by AAGUID and the next time it should be refreshed.
+func fetchBlob(client *http.Client, url string, root *x509.Certificate) (map[string]AuthenticatorStatus, time.Time, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("fetch MDS blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("fetch MDS blob: unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read MDS blob: %w", err)
+	}
+
+	// Pin the accepted signing algorithms explicitly rather than relying on
+	// the x5c leaf's public key type rejecting HMAC tokens implicitly - the
+	// FIDO MDS3 BLOB is always RS256 or ES256.
+	var claims payload
+	_, err = jwt.ParseWithClaims(string(body), (*jwtClaims)(&claims), func(token *jwt.Token) (any, error) {
+		return verificationKey(token, root)
+	}, jwt.WithValidMethods([]string{"RS256", "ES256"}))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("verify MDS blob: %w", err)
+	}
+
+	nextUpdate, err := time.Parse("2006-01-02", claims.NextUpdate)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("parse nextUpdate: %w", err)
+	}
+
+	entries := make(map[string]AuthenticatorStatus, len(claims.Entries))
+	for _, e := range claims.Entries {
+		if e.AAGUID == "" {
+			continue
+		}
+		entries[e.AAGUID] = AuthenticatorStatus{
+			AAGUID:             e.AAGUID,
+			Name:               e.MetadataStatement.Description,
+			CertificationLevel: latestCertificationLevel(e.StatusReports),
+		}
+	}
+
+	return entries, nextUpdate, nil
+}
+
+// latestCertificationLevel returns the last FIDO_CERTIFIED* status in the
+// reports, e.g. "FIDO_CERTIFIED_L1", or "" if there is none.
+func latestCertificationLevel(reports []report) string {
+	for i := len(reports) - 1; i >= 0; i-- {
+		if strings.HasPrefix(reports[i].Status, "FIDO_CERTIFIED") {
+			return reports[i].Status
+		}
+	}
+	return ""
+}
+
+// jwtClaims adapts payload to jwt.Claims so jwt.ParseWithClaims can decode
+// straight into it; the BLOB doesn't carry standard registered claims.
+type jwtClaims payload
+
+func (c *jwtClaims) GetExpirationTime() (*jwt.NumericDate, error) { return nil, nil }
+func (c *jwtClaims) GetIssuedAt() (*jwt.NumericDate, error)       { return nil, nil }
+func (c *jwtClaims) GetNotBefore() (*jwt.NumericDate, error)      { return nil, nil }
+func (c *jwtClaims) GetIssuer() (string, error)                  { return "", nil }
+func (c *jwtClaims) GetSubject() (string, error)                 { return "", nil }
+func (c *jwtClaims) GetAudience() (jwt.ClaimStrings, error)      { return nil, nil }
+
+// verificationKey walks the token's x5c certificate chain and checks it's
+// signed by the FIDO root CA, returning the leaf cert's public key to verify
+// the JWT signature itself.
+func verificationKey(token *jwt.Token, root *x509.Certificate) (any, error) {
+	chain, ok := token.Header["x5c"].([]any)
+	if !ok || len(chain) == 0 {
+		return nil, fmt.Errorf("missing x5c certificate chain")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(chain))
+	for _, raw := range chain {
+		der, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("malformed x5c entry")
+		}
+		cert, err := parseBase64Cert(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{Roots: pool, Intermediates: intermediates}); err != nil {
+		return nil, fmt.Errorf("verify x5c chain against FIDO root: %w", err)
+	}
+
+	return certs[0].PublicKey, nil
+}
+
+func parseBase64Cert(b64 string) (*x509.Certificate, error) {
+	der, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("decode x5c entry: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse x5c entry: %w", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,176 @@
+// Package metadata caches the FIDO Alliance Metadata Service (MDS3) BLOB so
+// the server can tell what kind of authenticator a credential came from, and
+// optionally refuse to enroll ones that aren't on an enterprise allow list.
+package metadata
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/google/uuid"
+)
+
+const defaultBlobURL = "https://mds3.fidoalliance.org/"
+
+// defaultRefreshInterval is used as a fallback when the BLOB's nextUpdate is
+// in the past or unparsable, so the cache keeps retrying instead of going stale forever.
+const defaultRefreshInterval = 24 * time.Hour
+
+// AuthenticatorStatus is the resolved MDS3 record for one AAGUID.
+type AuthenticatorStatus struct {
+	AAGUID             string
+	Name               string
+	CertificationLevel string
+}
+
+// Config controls how the cache fetches the BLOB and, optionally, which
+// authenticators enterprise deployments allow.
+type Config struct {
+	// BlobURL defaults to the FIDO Alliance's production MDS3 endpoint.
+	BlobURL string
+	// RootCA is the FIDO root certificate the BLOB's signing chain must
+	// chain up to.
+	RootCA *x509.Certificate
+	// AllowedAAGUIDs, if non-empty, is the only set of authenticators
+	// Allowed accepts. GetEntry does not consult it.
+	AllowedAAGUIDs map[string]bool
+	// DeniedAAGUIDs is checked before AllowedAAGUIDs and always wins.
+	DeniedAAGUIDs map[string]bool
+	// MinCertificationLevel, if set, rejects authenticators certified below
+	// it. Levels compare as FIDO defines them, e.g. "FIDO_CERTIFIED_L1".
+	MinCertificationLevel string
+}
+
+// Cache is a refreshing in-memory view of the MDS3 BLOB. It implements the
+// go-webauthn metadata.Provider interface so it can be wired directly into
+// webauthn.Config.MDS.
+type Cache struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.RWMutex
+	entries map[string]AuthenticatorStatus
+}
+
+// NewCache builds a cache and performs the first BLOB fetch synchronously so
+// the server doesn't start in a half-initialized state. Call Start to keep it
+// refreshing in the background.
+func NewCache(cfg Config) (*Cache, error) {
+	if cfg.BlobURL == "" {
+		cfg.BlobURL = defaultBlobURL
+	}
+
+	c := &Cache{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		entries: map[string]AuthenticatorStatus{},
+	}
+
+	if _, err := c.refresh(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start refreshes the BLOB on its own nextUpdate cadence until ctx is
+// cancelled.
+func (c *Cache) Start(ctx context.Context) {
+	go func() {
+		for {
+			interval, err := c.refresh()
+			if err != nil {
+				log.Printf("metadata: MDS3 BLOB refresh failed, retrying later: %v", err)
+				interval = defaultRefreshInterval
+			}
+
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// refresh re-fetches the BLOB and returns how long to wait before the next
+// attempt (the BLOB's own nextUpdate cadence).
+func (c *Cache) refresh() (time.Duration, error) {
+	entries, nextUpdate, err := fetchBlob(c.client, c.cfg.BlobURL, c.cfg.RootCA)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.entries = entries
+	c.mu.Unlock()
+
+	wait := time.Until(nextUpdate)
+	if wait <= 0 {
+		wait = defaultRefreshInterval
+	}
+	return wait, nil
+}
+
+// Entry returns the resolved authenticator record for an AAGUID, if MDS3
+// knows about it.
+func (c *Cache) Entry(aaguid string) (AuthenticatorStatus, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[aaguid]
+	return entry, ok
+}
+
+// GetEntry implements the go-webauthn metadata.Provider interface so the
+// cache can be wired directly into webauthn.Config.MDS. go-webauthn calls
+// this during CreateCredential to enforce attestation against the BLOB.
+func (c *Cache) GetEntry(_ context.Context, aaguid uuid.UUID) (*metadata.Entry, error) {
+	entry, ok := c.Entry(aaguid.String())
+	if !ok {
+		return nil, fmt.Errorf("metadata: no MDS3 entry for aaguid %s", aaguid)
+	}
+
+	return &metadata.Entry{
+		AaGUID: aaguid,
+		MetadataStatement: metadata.MetadataStatement{
+			Description: entry.Name,
+		},
+		StatusReports: []metadata.StatusReport{
+			{Status: metadata.AuthenticatorStatus(entry.CertificationLevel)},
+		},
+	}, nil
+}
+
+// Allowed reports whether an AAGUID passes the configured allow/deny list and
+// minimum certification level. Authenticators MDS3 doesn't know about are
+// rejected once an allow list or minimum certification level is configured;
+// with only a deny list, they are allowed.
+func (c *Cache) Allowed(aaguid string) bool {
+	if c.cfg.DeniedAAGUIDs[aaguid] {
+		return false
+	}
+
+	if len(c.cfg.AllowedAAGUIDs) == 0 && c.cfg.MinCertificationLevel == "" {
+		return true
+	}
+
+	entry, ok := c.Entry(aaguid)
+	if !ok {
+		return false
+	}
+
+	if len(c.cfg.AllowedAAGUIDs) > 0 && !c.cfg.AllowedAAGUIDs[aaguid] {
+		return false
+	}
+
+	if c.cfg.MinCertificationLevel != "" && entry.CertificationLevel < c.cfg.MinCertificationLevel {
+		return false
+	}
+
+	return true
+}
@@ -1,16 +1,40 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/google/uuid"
 )
 
 type Credential struct {
-	ID         []byte
-	UserID     uuid.UUID
-	PublicKey  []byte
-	SignCount  uint32
-	Transports []string
-	CreatedAt  time.Time
+	ID         []byte    `json:"id"`
+	UserID     uuid.UUID `json:"-"`
+	PublicKey  []byte    `json:"-"`
+	SignCount  uint32    `json:"-"`
+	Transports []string  `json:"transports"`
+	AAGUID     []byte    `json:"-"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// MarshalJSON encodes ID as protocol.URLEncodedBase64 (unpadded base64url),
+// the same encoding WebAuthn itself uses for credential IDs, so a client can
+// round-trip the "id" field straight back into a path parameter. AAGUID is
+// surfaced the same way it's already logged and looked up elsewhere - as the
+// uuid.UUID it identifies the authenticator model by.
+func (c Credential) MarshalJSON() ([]byte, error) {
+	type alias Credential
+	aaguid, _ := uuid.FromBytes(c.AAGUID)
+	return json.Marshal(struct {
+		ID     protocol.URLEncodedBase64 `json:"id"`
+		AAGUID uuid.UUID                 `json:"aaguid"`
+		alias
+	}{
+		ID:     protocol.URLEncodedBase64(c.ID),
+		AAGUID: aaguid,
+		alias:  alias(c),
+	})
 }